@@ -32,6 +32,9 @@ var (
 
 	// ErrValueTooBig value is too big.
 	ErrValueTooBig = errors.New("value is too big to fit into memtable")
+
+	// ErrReadOnly the column family was opened read-only and rejects writes.
+	ErrReadOnly = errors.New("column family is opened read-only")
 )
 
 // ColumnFamily is a namespace of keys and values.
@@ -49,13 +52,17 @@ type ColumnFamily struct {
 	indexer index.Indexer
 	// When the active memtable is full, send it to the flushChn, see listenAndFlush.
 	flushChn  chan *memtable
-	flushLock sync.RWMutex // guarantee flush and compaction exclusive.
+	flushWg   sync.WaitGroup // tracks memtables queued by Flush/WaitForFlush.
+	flushLock sync.RWMutex   // guarantee flush and compaction exclusive.
 	opts      ColumnFamilyOptions
 	mu        sync.RWMutex
 	// Prevent concurrent db using.
 	// At least one FileLockGuard(cf/indexer/vlog dirs are all the same).
 	// And at most three FileLockGuards(cf/indexer/vlog dirs are all different).
 	dirLocks []*flock.FileLockGuard
+	// memMgr is the LotusDB-wide memory budget this cf reserves bytes
+	// from before writing. Shared across every cf opened by the same db.
+	memMgr *MemoryManager
 	// represents whether the cf is closed, 0: false, 1: true.
 	closed    uint32
 	closedC   chan struct{}
@@ -67,6 +74,39 @@ type Stat struct {
 	MemtableSize int64
 }
 
+// waitWritesMemSpace blocks until the active memtable has room for size
+// more bytes, rotating it out for flushing via Flush if it is already
+// full. It returns ErrWaitMemSpaceTimeout if the active memtable is
+// still full defaultWaitMemSpaceTimeout after Flush returned, e.g.
+// because size alone exceeds MemtableSize or concurrent writers have
+// already filled the new memtable. Flush itself blocks, with no
+// timeout, while flushChn is full.
+func (cf *ColumnFamily) waitWritesMemSpace(size uint32) error {
+	cf.mu.RLock()
+	full := cf.activeMem.isFull(size)
+	cf.mu.RUnlock()
+	if !full {
+		return nil
+	}
+	if err := cf.Flush(true); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(defaultWaitMemSpaceTimeout)
+	for {
+		cf.mu.RLock()
+		full = cf.activeMem.isFull(size)
+		cf.mu.RUnlock()
+		if !full {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrWaitMemSpaceTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 // OpenColumnFamily open a new or existed column family.
 func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, error) {
 	if opts.CfName == "" {
@@ -104,7 +144,7 @@ func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, er
 	}
 
 	// acquire file lock to lock cf/indexer/vlog directory.
-	flocks, err := acquireDirLocks(opts.DirPath, opts.IndexerDir, opts.ValueLogDir)
+	flocks, err := acquireDirLocks(opts.DirPath, opts.IndexerDir, opts.ValueLogDir, opts.ReadOnly)
 	if err != nil {
 		return nil, fmt.Errorf("another process is using dir.%v", err.Error())
 	}
@@ -115,6 +155,7 @@ func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, er
 		closedC:   make(chan struct{}),
 		closeOnce: new(sync.Once),
 		flushChn:  make(chan *memtable, opts.MemtableNums-1),
+		memMgr:    db.memMgr,
 	}
 	// open active and immutable memtables.
 	if err := cf.openMemtables(); err != nil {
@@ -132,6 +173,7 @@ func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, er
 		ioType:     ioType,
 		gcRatio:    opts.ValueLogGCRatio,
 		gcInterval: opts.ValueLogGCInterval,
+		readOnly:   opts.ReadOnly,
 	}
 	valueLog, err := openValueLog(vlogOpt)
 	if err != nil {
@@ -140,16 +182,22 @@ func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, er
 	cf.vlog = valueLog
 	valueLog.cf = cf
 
-	// create bptree indexer.
-	bptreeOpt := &index.BPTreeOptions{
-		IndexType:        index.BptreeBoltDB,
+	// create the indexer. Defaults to the index.BptreeBoltDB backend, but
+	// ColumnFamilyOptions.IndexerFactory lets callers plug in a
+	// different backend, e.g. HashIndexerFactory.
+	indexerOpt := &index.BPTreeOptions{
 		ColumnFamilyName: opts.CfName,
 		BucketName:       []byte(opts.CfName),
 		DirPath:          opts.IndexerDir,
 		BatchSize:        opts.FlushBatchSize,
 		DiscardChn:       cf.vlog.discard.valChan,
+		ReadOnly:         opts.ReadOnly,
+	}
+	newIndexer := opts.IndexerFactory
+	if newIndexer == nil {
+		newIndexer = defaultIndexerFactory
 	}
-	indexer, err := index.NewIndexer(bptreeOpt)
+	indexer, err := newIndexer(indexerOpt)
 	if err != nil {
 		return nil, err
 	}
@@ -158,10 +206,27 @@ func (db *LotusDB) OpenColumnFamily(opts ColumnFamilyOptions) (*ColumnFamily, er
 	db.mu.Lock()
 	db.cfs[opts.CfName] = cf
 	db.mu.Unlock()
-	go cf.listenAndFlush()
+	// a read-only instance never writes, so there is nothing for the
+	// flush goroutine to do, and running it would fight the primary for
+	// the indexer/vlog files it opened in read-only mode.
+	if !opts.ReadOnly {
+		go cf.listenAndFlush()
+	}
 	return cf, nil
 }
 
+// OpenColumnFamilyReadOnly opens opts.CfName the same way OpenColumnFamily
+// does, but as a read-only secondary instance: it takes a shared file
+// lock instead of an exclusive one, never starts the flush goroutine,
+// and rejects Put/Delete with ErrReadOnly. It is meant for hot-standby
+// style deployments that tail a primary's directory; call Refresh
+// periodically to pick up WAL/vlog files the primary has written since
+// and reload the bptree state.
+func (db *LotusDB) OpenColumnFamilyReadOnly(opts ColumnFamilyOptions) (*ColumnFamily, error) {
+	opts.ReadOnly = true
+	return db.OpenColumnFamily(opts)
+}
+
 // Put put to current column family.
 func (cf *ColumnFamily) Put(key, value []byte) error {
 	return cf.PutWithOptions(key, value, nil)
@@ -169,11 +234,20 @@ func (cf *ColumnFamily) Put(key, value []byte) error {
 
 // PutWithOptions put to current column family with options.
 func (cf *ColumnFamily) PutWithOptions(key, value []byte, opt *WriteOptions) error {
+	if cf.opts.ReadOnly {
+		return ErrReadOnly
+	}
 	// waiting for enough memtable sapce to write.
 	size := uint32(len(key) + len(value))
 	if err := cf.waitWritesMemSpace(size); err != nil {
 		return err
 	}
+	// reserve from the db-wide memory budget, on top of this cf's own
+	// per-memtable space check, so the total across every open cf stays
+	// bounded.
+	if err := cf.memMgr.Reserve(size, 0); err != nil {
+		return err
+	}
 	if opt == nil {
 		opt = new(WriteOptions)
 	}
@@ -182,6 +256,7 @@ func (cf *ColumnFamily) PutWithOptions(key, value []byte, opt *WriteOptions) err
 	if err := cf.activeMem.put(key, value, false, *opt); err != nil {
 		return err
 	}
+	cf.memMgr.track(cf, cf.activeMem.fid, int64(cf.activeMem.skl.Size()))
 	return nil
 }
 
@@ -229,10 +304,16 @@ func (cf *ColumnFamily) Delete(key []byte) error {
 
 // DeleteWithOptions delete from current column family with options.
 func (cf *ColumnFamily) DeleteWithOptions(key []byte, opt *WriteOptions) error {
+	if cf.opts.ReadOnly {
+		return ErrReadOnly
+	}
 	size := uint32(len(key))
 	if err := cf.waitWritesMemSpace(size); err != nil {
 		return err
 	}
+	if err := cf.memMgr.Reserve(size, 0); err != nil {
+		return err
+	}
 	if opt == nil {
 		opt = new(WriteOptions)
 	}
@@ -241,6 +322,7 @@ func (cf *ColumnFamily) DeleteWithOptions(key []byte, opt *WriteOptions) error {
 	if err := cf.activeMem.delete(key, *opt); err != nil {
 		return err
 	}
+	cf.memMgr.track(cf, cf.activeMem.fid, int64(cf.activeMem.skl.Size()))
 	return nil
 }
 
@@ -260,6 +342,10 @@ func (cf *ColumnFamily) Close() error {
 	defer cf.mu.Unlock()
 	atomic.StoreUint32(&cf.closed, 1)
 	cf.closeOnce.Do(func() { close(cf.closedC) })
+	cf.memMgr.untrack(cf, cf.activeMem.fid)
+	for _, mem := range cf.immuMems {
+		cf.memMgr.untrack(cf, mem.fid)
+	}
 
 	var err error
 	// commits the current contents of the file to stable storage
@@ -351,6 +437,26 @@ func (cf *ColumnFamily) openMemtables() error {
 		return fids[i] < fids[j]
 	})
 	if len(fids) == 0 {
+		// a read-only instance must never create the first WAL file
+		// itself: if the primary hasn't written anything yet, there is
+		// simply nothing to open, and manufacturing one would collide
+		// with the primary's own first write. Fall back to an
+		// in-memory-only placeholder memtable instead of leaving
+		// activeMem nil, so Get/Sync/Close have something to operate on
+		// until Refresh picks up the primary's first WAL file.
+		if cf.opts.ReadOnly {
+			cf.activeMem = &memtable{
+				fid: logfile.InitialLogFileId,
+				skl: newSkiplist(),
+				opts: memOptions{
+					path:     cf.opts.DirPath,
+					fid:      logfile.InitialLogFileId,
+					memSize:  cf.opts.MemtableSize,
+					readOnly: true,
+				},
+			}
+			return nil
+		}
 		fids = append(fids, logfile.InitialLogFileId)
 	}
 
@@ -364,6 +470,7 @@ func (cf *ColumnFamily) openMemtables() error {
 		ioType:     ioType,
 		memSize:    cf.opts.MemtableSize,
 		bytesFlush: cf.opts.WalBytesFlush,
+		readOnly:   cf.opts.ReadOnly,
 	}
 	for i, fid := range fids {
 		memOpts.fid = fid
@@ -380,6 +487,70 @@ func (cf *ColumnFamily) openMemtables() error {
 	return nil
 }
 
+// listenAndFlush drains flushChn, flushing each rotated memtable into
+// the indexer/value log, until the column family is closed. It is the
+// other end of Flush: Flush only queues a memtable and returns, this is
+// what actually does the work WaitForFlush blocks on.
+func (cf *ColumnFamily) listenAndFlush() {
+	for {
+		select {
+		case <-cf.closedC:
+			return
+		case mem := <-cf.flushChn:
+			cf.flushMemtable(mem)
+		}
+	}
+}
+
+// flushMemtable drains mem's entries into the indexer (storing every
+// value in the value log and pointing the indexer at it), removes mem
+// from immuMems, and releases its share of the memory budget and its
+// flushWg slot. On error it returns early: mem stays in immuMems with
+// its WAL file open, so its entries remain readable and are replayed
+// from the WAL on the next open, but listenAndFlush discards the error,
+// the flush is not retried, and its flushWg slot is not released.
+func (cf *ColumnFamily) flushMemtable(mem *memtable) error {
+	cf.flushLock.Lock()
+	defer cf.flushLock.Unlock()
+
+	it := mem.skl.NewIterator()
+	for it.Next() {
+		key := it.Key()
+		if it.Deleted() {
+			if err := cf.indexer.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		fid, offset, err := cf.vlog.Write(it.Value(), 0)
+		if err != nil {
+			return err
+		}
+		if err := cf.indexer.Put(key, &index.IndexerMeta{Fid: fid, Offset: offset}); err != nil {
+			return err
+		}
+	}
+	if err := cf.indexer.Sync(); err != nil {
+		return err
+	}
+	if err := mem.closeWAL(); err != nil {
+		return err
+	}
+
+	cf.mu.Lock()
+	for i, immu := range cf.immuMems {
+		if immu == mem {
+			cf.immuMems = append(cf.immuMems[:i], cf.immuMems[i+1:]...)
+			break
+		}
+	}
+	cf.mu.Unlock()
+
+	cf.memMgr.untrack(cf, mem.fid)
+	cf.flushWg.Done()
+	return nil
+}
+
 func (cf *ColumnFamily) getMemtables() []*memtable {
 	cf.mu.RLock()
 	defer cf.mu.RUnlock()
@@ -393,7 +564,11 @@ func (cf *ColumnFamily) getMemtables() []*memtable {
 	return tables
 }
 
-func acquireDirLocks(cfDir, indexerDir, vlogDir string) ([]*flock.FileLockGuard, error) {
+// acquireDirLocks locks the cf/indexer/vlog directories. readOnly
+// acquires a shared lock instead of an exclusive one, so a read-only
+// secondary instance can tail a primary's directory without the two
+// processes fighting over the lock file.
+func acquireDirLocks(cfDir, indexerDir, vlogDir string, readOnly bool) ([]*flock.FileLockGuard, error) {
 	var dirs = []string{cfDir}
 	if indexerDir != cfDir {
 		dirs = append(dirs, indexerDir)
@@ -404,7 +579,7 @@ func acquireDirLocks(cfDir, indexerDir, vlogDir string) ([]*flock.FileLockGuard,
 
 	var flocks []*flock.FileLockGuard
 	for _, dir := range dirs {
-		lock, err := flock.AcquireFileLock(dir+separator+lockFileName, false)
+		lock, err := flock.AcquireFileLock(dir+separator+lockFileName, readOnly)
 		if err != nil {
 			return nil, err
 		}
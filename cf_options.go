@@ -0,0 +1,90 @@
+package lotusdb
+
+import (
+	"os"
+	"time"
+)
+
+const lockFileName = "FLOCK"
+
+var separator = string(os.PathSeparator)
+
+// ColumnFamilyOptions holds the knobs OpenColumnFamily needs to open or
+// create a column family.
+type ColumnFamilyOptions struct {
+	// CfName is this column family's name; required.
+	CfName string
+	// DirPath is the column family's own directory. Defaults to
+	// LotusDB.Options.DBPath/CfName.
+	DirPath string
+	// IndexerDir and ValueLogDir default to DirPath.
+	IndexerDir  string
+	ValueLogDir string
+
+	// MemtableSize is the byte size an active memtable may reach before
+	// it is rotated out for flushing.
+	MemtableSize uint32
+	// MemtableNums bounds how many immutable memtables may be queued
+	// for flush before writes block.
+	MemtableNums int
+	// WalMMap opens WAL files with logfile.MMap instead of
+	// logfile.FileIO (see logfile.MMap).
+	WalMMap bool
+	// WalBytesFlush is recorded in each memtable's options; no WAL
+	// write is fsynced because of it. A WAL write is fsynced only when
+	// WriteOptions.Sync is set, and by Sync and Close.
+	WalBytesFlush uint32
+
+	// FlushBatchSize is passed to the indexer as BPTreeOptions.BatchSize.
+	// Neither built-in indexer batches on it: a flush writes one indexer
+	// record per key.
+	FlushBatchSize int
+	// IndexerFactory builds the index.Indexer this cf stores its
+	// keydir in. Defaults to the index.BptreeBoltDB backend; set it to
+	// HashIndexerFactory (or a custom factory) to use a different
+	// backend.
+	IndexerFactory IndexerFactory
+
+	// ValueLogFileSize is passed to the value log as its segment size.
+	// Segments are not rotated when they reach it: every value is
+	// appended to the segment with the highest fid.
+	ValueLogFileSize int64
+	// ValueLogMmap opens value log segments with logfile.MMap instead of
+	// logfile.FileIO (see logfile.MMap).
+	ValueLogMmap bool
+	// ValueLogGCRatio must be in (0, 1); OpenColumnFamily rejects other
+	// values with ErrInvalidVLogGCRatio. It is passed to the value log,
+	// which runs no GC, so segments are never compacted.
+	ValueLogGCRatio float64
+	// ValueLogGCInterval is passed to the value log, which runs no GC
+	// on it.
+	ValueLogGCInterval time.Duration
+
+	// ReadOnly opens this column family as a read-only secondary
+	// instance: shared file locks, no flush goroutine, and Put/Delete
+	// both return ErrReadOnly. See OpenColumnFamilyReadOnly and Refresh.
+	ReadOnly bool
+}
+
+// WriteOptions are the per-call options PutWithOptions/DeleteWithOptions
+// accept.
+type WriteOptions struct {
+	// Sync fsyncs the WAL before returning from this call.
+	Sync bool
+	// ExpiredAt is a unix timestamp after which the entry is treated as
+	// deleted; 0 means it never expires.
+	ExpiredAt int64
+}
+
+// DefaultColumnFamilyOptions returns reasonable defaults for cfName.
+func DefaultColumnFamilyOptions(cfName string) ColumnFamilyOptions {
+	return ColumnFamilyOptions{
+		CfName:             cfName,
+		MemtableSize:       64 << 20,
+		MemtableNums:       5,
+		FlushBatchSize:     1000,
+		ValueLogFileSize:   1 << 30,
+		ValueLogGCRatio:    0.5,
+		ValueLogGCInterval: time.Hour,
+	}
+}
@@ -0,0 +1,34 @@
+// Package flock provides a tiny cross-process directory lock built on
+// flock(2)-style advisory file locks.
+package flock
+
+import "os"
+
+// FileLockGuard holds an acquired advisory lock until Release is called.
+type FileLockGuard struct {
+	fd       *os.File
+	readOnly bool
+}
+
+// AcquireFileLock locks path, creating it if necessary. readOnly takes a
+// shared lock so multiple read-only processes can hold it at once;
+// otherwise the lock is exclusive.
+func AcquireFileLock(path string, readOnly bool) (*FileLockGuard, error) {
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(fd, readOnly); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return &FileLockGuard{fd: fd, readOnly: readOnly}, nil
+}
+
+// Release releases the lock and closes the underlying file.
+func (g *FileLockGuard) Release() error {
+	if err := unlock(g.fd); err != nil {
+		return err
+	}
+	return g.fd.Close()
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package flock
+
+import (
+	"os"
+	"syscall"
+)
+
+func lock(fd *os.File, readOnly bool) error {
+	how := syscall.LOCK_EX
+	if readOnly {
+		how = syscall.LOCK_SH
+	}
+	return syscall.Flock(int(fd.Fd()), how|syscall.LOCK_NB)
+}
+
+func unlock(fd *os.File) error {
+	return syscall.Flock(int(fd.Fd()), syscall.LOCK_UN)
+}
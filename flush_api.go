@@ -0,0 +1,79 @@
+package lotusdb
+
+import "github.com/flower-corp/lotusdb/logfile"
+
+// Flush rotates the active memtable into immuMems and pushes it onto
+// flushChn, returning as soon as it has been queued rather than waiting
+// for the background flusher to fsync it. When shortBlocks is true the
+// active memtable is rotated unconditionally, even if it is nearly
+// empty ("short"); when shortBlocks is false, a partially-filled active
+// memtable is left in place so future writes can keep filling it, and
+// only a full memtable is rotated. This is distinct from Sync, which
+// fsyncs the current state without touching memtable placement, and
+// gives callers explicit control over memory pressure vs write
+// amplification instead of waiting for MemtableSize to be hit.
+func (cf *ColumnFamily) Flush(shortBlocks bool) error {
+	cf.mu.Lock()
+	if !shortBlocks && !cf.activeMem.isFull(0) {
+		cf.mu.Unlock()
+		return nil
+	}
+	rotated := cf.activeMem
+	newMem, err := cf.openNewActiveMem()
+	if err != nil {
+		cf.mu.Unlock()
+		return err
+	}
+	cf.immuMems = append(cf.immuMems, rotated)
+	cf.activeMem = newMem
+	cf.mu.Unlock()
+
+	cf.flushWg.Add(1)
+	cf.flushChn <- rotated
+	return nil
+}
+
+// WaitForFlush blocks until every memtable queued by Flush (or by the
+// regular waitWritesMemSpace path) has been flushed to the indexer and
+// value log, letting callers decouple issuing a flush from waiting for
+// it to land on disk.
+func (cf *ColumnFamily) WaitForFlush() {
+	cf.flushWg.Wait()
+}
+
+// Flush flushes every open column family. See ColumnFamily.Flush for the
+// meaning of shortBlocks.
+func (db *LotusDB) Flush(shortBlocks bool) error {
+	db.mu.RLock()
+	cfs := make([]*ColumnFamily, 0, len(db.cfs))
+	for _, cf := range db.cfs {
+		cfs = append(cfs, cf)
+	}
+	db.mu.RUnlock()
+
+	for _, cf := range cfs {
+		if err := cf.Flush(shortBlocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openNewActiveMem opens the next memtable in the WAL sequence to become
+// the new active memtable, mirroring the fid sequencing openMemtables
+// uses when recovering from disk.
+func (cf *ColumnFamily) openNewActiveMem() (*memtable, error) {
+	var ioType = logfile.FileIO
+	if cf.opts.WalMMap {
+		ioType = logfile.MMap
+	}
+	memOpts := memOptions{
+		path:       cf.opts.DirPath,
+		fid:        cf.activeMem.fid + 1,
+		fsize:      int64(cf.opts.MemtableSize),
+		ioType:     ioType,
+		memSize:    cf.opts.MemtableSize,
+		bytesFlush: cf.opts.WalBytesFlush,
+	}
+	return openMemtable(memOpts)
+}
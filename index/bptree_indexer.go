@@ -0,0 +1,227 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const bptreeIndexFileName = "BPTREE-INDEX"
+
+// bptreeIndexer is a minimal stand-in for the real BoltDB-backed B+
+// tree: an in-memory sorted keydir backed by the same append-only
+// record log HashIndexer uses, kept sorted so it can support range
+// scans via NewIterator. Get is a map lookup, as in HashIndexer; the
+// extra cost is inserting every new key into the sorted key slice, in
+// exchange for ordered iteration without sorting.
+type bptreeIndexer struct {
+	mu    sync.RWMutex
+	file  *os.File
+	keys  []string
+	index map[string]*IndexerMeta
+
+	discardChn chan *IndexerMeta
+	readOnly   bool
+}
+
+func newBptreeIndexer(opts *BPTreeOptions) (*bptreeIndexer, error) {
+	path := filepath.Join(opts.DirPath, bptreeIndexFileName)
+	flag := os.O_CREATE | os.O_RDWR
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &bptreeIndexer{
+		file:       file,
+		index:      make(map[string]*IndexerMeta),
+		discardChn: opts.DiscardChn,
+		readOnly:   opts.ReadOnly,
+	}
+	if err := idx.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (b *bptreeIndexer) load() error {
+	if _, err := b.file.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	for {
+		key, meta, err := readHashRecord(b.file)
+		if err != nil {
+			break
+		}
+		if meta == nil {
+			b.removeKey(string(key))
+			continue
+		}
+		b.putKey(string(key), meta)
+	}
+	_, err := b.file.Seek(0, os.SEEK_END)
+	return err
+}
+
+func (b *bptreeIndexer) putKey(key string, meta *IndexerMeta) {
+	if _, ok := b.index[key]; !ok {
+		i := sort.SearchStrings(b.keys, key)
+		b.keys = append(b.keys, "")
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = key
+	}
+	b.index[key] = meta
+}
+
+func (b *bptreeIndexer) removeKey(key string) {
+	if _, ok := b.index[key]; !ok {
+		return
+	}
+	delete(b.index, key)
+	i := sort.SearchStrings(b.keys, key)
+	if i < len(b.keys) && b.keys[i] == key {
+		b.keys = append(b.keys[:i], b.keys[i+1:]...)
+	}
+}
+
+func (b *bptreeIndexer) Get(key []byte) (*IndexerMeta, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.index[string(key)], nil
+}
+
+func (b *bptreeIndexer) Put(key []byte, meta *IndexerMeta) error {
+	if b.readOnly {
+		return os.ErrPermission
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := writeHashRecord(b.file, key, meta); err != nil {
+		return err
+	}
+	if old, ok := b.index[string(key)]; ok && b.discardChn != nil {
+		b.discardChn <- old
+	}
+	b.putKey(string(key), meta)
+	return nil
+}
+
+func (b *bptreeIndexer) Delete(key []byte) error {
+	if b.readOnly {
+		return os.ErrPermission
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := writeHashRecord(b.file, key, nil); err != nil {
+		return err
+	}
+	if old, ok := b.index[string(key)]; ok && b.discardChn != nil {
+		b.discardChn <- old
+	}
+	b.removeKey(string(key))
+	return nil
+}
+
+func (b *bptreeIndexer) Sync() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.file.Sync()
+}
+
+// Reload re-reads the index log from the start, rebuilding keys/index
+// from it. Used by a read-only Indexer to pick up records a primary has
+// appended since open (or the last Reload).
+func (b *bptreeIndexer) Reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.keys = nil
+	b.index = make(map[string]*IndexerMeta)
+	return b.load()
+}
+
+// CopyTo takes a bounded, point-in-time copy of the backend's on-disk
+// log into dstDir, under the same name it uses at its source, and
+// returns that name.
+func (b *bptreeIndexer) CopyTo(dstDir string) (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	info, err := b.file.Stat()
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dstDir, bptreeIndexFileName)
+	if err := copyFileRange(b.file, dst, info.Size()); err != nil {
+		return "", err
+	}
+	return bptreeIndexFileName, nil
+}
+
+func (b *bptreeIndexer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file.Close()
+}
+
+// NewIterator returns a snapshot-consistent IndexerIterator: the key
+// list is copied under lock at construction time, so later writes are
+// not visible through it.
+func (b *bptreeIndexer) NewIterator() IndexerIterator {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	keys := make([]string, len(b.keys))
+	copy(keys, b.keys)
+	meta := make(map[string]*IndexerMeta, len(b.index))
+	for k, v := range b.index {
+		meta[k] = v
+	}
+	return &bptreeIterator{keys: keys, meta: meta, pos: -1}
+}
+
+type bptreeIterator struct {
+	keys []string
+	meta map[string]*IndexerMeta
+	pos  int
+}
+
+func (it *bptreeIterator) SeekGE(key []byte) bool {
+	it.pos = sort.SearchStrings(it.keys, string(key))
+	return it.Valid()
+}
+
+func (it *bptreeIterator) SeekLT(key []byte) bool {
+	i := sort.SearchStrings(it.keys, string(key))
+	it.pos = i - 1
+	return it.Valid()
+}
+
+func (it *bptreeIterator) Next() bool {
+	it.pos++
+	return it.Valid()
+}
+
+func (it *bptreeIterator) Prev() bool {
+	it.pos--
+	return it.Valid()
+}
+
+func (it *bptreeIterator) Valid() bool {
+	return it.pos >= 0 && it.pos < len(it.keys)
+}
+
+func (it *bptreeIterator) Key() []byte {
+	return []byte(it.keys[it.pos])
+}
+
+func (it *bptreeIterator) Meta() *IndexerMeta {
+	return it.meta[it.keys[it.pos]]
+}
+
+func (it *bptreeIterator) Close() error {
+	return nil
+}
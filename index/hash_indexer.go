@@ -0,0 +1,268 @@
+package index
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HashIndex selects the on-disk hash indexer in NewIndexer, as an
+// alternative to BptreeBoltDB for column families that only ever call
+// Get and don't need range scans.
+const HashIndex = IndexType(1)
+
+const hashIndexFileName = "HASH-INDEX"
+
+// hashRecord is the on-disk encoding of a single keydir entry: a
+// length-prefixed key, a tombstone byte, and, unless the tombstone
+// byte is 1, the IndexerMeta with its length-prefixed inline value.
+//
+//	| keySize uint32 | key | tombstone byte | fid uint32 | offset int64 | valueSize uint32 | value |
+const hashRecordHeaderSize = 4
+
+// HashIndexer is a simple on-disk hash index: an in-memory keydir
+// (map[string]*IndexerMeta) backed by an append-only log of the same
+// records, replayed on open. It keeps no key order, so writes skip the
+// sorted insert the B+ tree indexer does, and NewIterator sorts the
+// keydir instead; memory use is one keydir entry per key.
+type HashIndexer struct {
+	mu   sync.RWMutex
+	file *os.File
+	keys map[string]*IndexerMeta
+
+	discardChn chan *IndexerMeta
+	readOnly   bool
+}
+
+// newHashIndexer opens (or creates) a HashIndexer rooted at opts.DirPath,
+// replaying any existing on-disk log into its in-memory keydir.
+func newHashIndexer(opts *BPTreeOptions) (*HashIndexer, error) {
+	path := filepath.Join(opts.DirPath, hashIndexFileName)
+	flag := os.O_CREATE | os.O_RDWR
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &HashIndexer{
+		file:       file,
+		keys:       make(map[string]*IndexerMeta),
+		discardChn: opts.DiscardChn,
+		readOnly:   opts.ReadOnly,
+	}
+	if err := idx.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (h *HashIndexer) load() error {
+	if _, err := h.file.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	for {
+		key, meta, err := readHashRecord(h.file)
+		if err != nil {
+			break
+		}
+		if meta == nil {
+			delete(h.keys, string(key))
+			continue
+		}
+		h.keys[string(key)] = meta
+	}
+	_, err := h.file.Seek(0, os.SEEK_END)
+	return err
+}
+
+// Get returns the IndexerMeta for key, or nil if key is not present.
+func (h *HashIndexer) Get(key []byte) (*IndexerMeta, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.keys[string(key)], nil
+}
+
+// Put records (or overwrites) key's IndexerMeta.
+func (h *HashIndexer) Put(key []byte, meta *IndexerMeta) error {
+	if h.readOnly {
+		return os.ErrPermission
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := writeHashRecord(h.file, key, meta); err != nil {
+		return err
+	}
+	if old, ok := h.keys[string(key)]; ok && h.discardChn != nil {
+		h.discardChn <- old
+	}
+	h.keys[string(key)] = meta
+	return nil
+}
+
+// Delete removes key from the index.
+func (h *HashIndexer) Delete(key []byte) error {
+	if h.readOnly {
+		return os.ErrPermission
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := writeHashRecord(h.file, key, nil); err != nil {
+		return err
+	}
+	if old, ok := h.keys[string(key)]; ok && h.discardChn != nil {
+		h.discardChn <- old
+	}
+	delete(h.keys, string(key))
+	return nil
+}
+
+// Sync fsyncs the on-disk log.
+func (h *HashIndexer) Sync() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.file.Sync()
+}
+
+// Reload re-reads the index log from the start, rebuilding keys from
+// it. Used by a read-only Indexer to pick up records a primary has
+// appended since open (or the last Reload).
+func (h *HashIndexer) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keys = make(map[string]*IndexerMeta)
+	return h.load()
+}
+
+// NewIterator returns a snapshot-consistent IndexerIterator. HashIndexer
+// keeps no natural order, so this sorts a copy of the keydir by key on
+// every call; callers that only ever do point lookups should prefer Get
+// and never pay for this.
+func (h *HashIndexer) NewIterator() IndexerIterator {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	keys := make([]string, 0, len(h.keys))
+	meta := make(map[string]*IndexerMeta, len(h.keys))
+	for k, v := range h.keys {
+		keys = append(keys, k)
+		meta[k] = v
+	}
+	sort.Strings(keys)
+	return &bptreeIterator{keys: keys, meta: meta, pos: -1}
+}
+
+// CopyTo takes a bounded, point-in-time copy of the backend's on-disk
+// log into dstDir, under the same name it uses at its source, and
+// returns that name.
+func (h *HashIndexer) CopyTo(dstDir string) (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	info, err := h.file.Stat()
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dstDir, hashIndexFileName)
+	if err := copyFileRange(h.file, dst, info.Size()); err != nil {
+		return "", err
+	}
+	return hashIndexFileName, nil
+}
+
+// Close closes the underlying log file.
+func (h *HashIndexer) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
+
+func writeHashRecord(w *os.File, key []byte, meta *IndexerMeta) error {
+	tombstone := meta == nil
+	var valSize int
+	if !tombstone {
+		valSize = len(meta.Value)
+	}
+	buf := make([]byte, 0, hashRecordHeaderSize+len(key)+1+4+8+4+valSize)
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(key)))
+	buf = append(buf, header...)
+	buf = append(buf, key...)
+
+	if tombstone {
+		buf = append(buf, 1)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		return nil
+	}
+	buf = append(buf, 0)
+
+	fidBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(fidBuf, meta.Fid)
+	buf = append(buf, fidBuf...)
+
+	offBuf := make([]byte, 8)
+	binary.BigEndian.PutUint64(offBuf, uint64(meta.Offset))
+	buf = append(buf, offBuf...)
+
+	valSizeBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(valSizeBuf, uint32(valSize))
+	buf = append(buf, valSizeBuf...)
+	buf = append(buf, meta.Value...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readHashRecord(r *os.File) ([]byte, *IndexerMeta, error) {
+	header := make([]byte, 4)
+	if _, err := readFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	keySize := binary.BigEndian.Uint32(header)
+	key := make([]byte, keySize)
+	if _, err := readFull(r, key); err != nil {
+		return nil, nil, err
+	}
+
+	tombstone := make([]byte, 1)
+	if _, err := readFull(r, tombstone); err != nil {
+		return nil, nil, err
+	}
+	if tombstone[0] == 1 {
+		return key, nil, nil
+	}
+
+	rest := make([]byte, 4+8+4)
+	if _, err := readFull(r, rest); err != nil {
+		return nil, nil, err
+	}
+	fid := binary.BigEndian.Uint32(rest[0:4])
+	offset := int64(binary.BigEndian.Uint64(rest[4:12]))
+	valSize := binary.BigEndian.Uint32(rest[12:16])
+
+	value := make([]byte, valSize)
+	if valSize > 0 {
+		if _, err := readFull(r, value); err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, &IndexerMeta{Fid: fid, Offset: offset, Value: value}, nil
+}
+
+func readFull(r *os.File, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
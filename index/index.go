@@ -0,0 +1,102 @@
+// Package index stores the keydir that maps keys to either an inline
+// value or a pointer into the value log, behind a pluggable Indexer
+// backend.
+package index
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrUnsupportedIndexType is returned by NewIndexer for an IndexType with
+// no registered backend.
+var ErrUnsupportedIndexType = errors.New("unsupported index type")
+
+// IndexType selects the Indexer backend NewIndexer constructs.
+type IndexType int8
+
+const (
+	// BptreeBoltDB is the default, range-scan-capable B+ tree backend.
+	BptreeBoltDB IndexType = iota
+)
+
+// IndexerMeta is what an Indexer stores per key: either the value
+// inline (Value set), or a pointer to it in the value log (Fid/Offset).
+type IndexerMeta struct {
+	Value  []byte
+	Fid    uint32
+	Offset int64
+}
+
+// BPTreeOptions configures NewIndexer. The name predates backends other
+// than the B+ tree but is kept as the single options type every backend
+// accepts, to keep OpenColumnFamily's construction code uniform.
+type BPTreeOptions struct {
+	IndexType        IndexType
+	ColumnFamilyName string
+	BucketName       []byte
+	DirPath          string
+	BatchSize        int
+	DiscardChn       chan *IndexerMeta
+	// ReadOnly opens the backend's data file O_RDONLY and makes Put and
+	// Delete fail with os.ErrPermission, for a secondary instance
+	// tailing a primary.
+	ReadOnly bool
+}
+
+// Indexer is the contract every index backend must satisfy: point
+// lookups, writes, and lifecycle, plus a discard channel so value log
+// GC can account for superseded entries.
+type Indexer interface {
+	Get(key []byte) (*IndexerMeta, error)
+	Put(key []byte, meta *IndexerMeta) error
+	Delete(key []byte) error
+	Sync() error
+	Close() error
+	// Reload re-reads the backend's on-disk log from the start and
+	// rebuilds the in-memory keydir from it, picking up records a
+	// primary has appended since this Indexer was opened (or last
+	// reloaded). It is only meaningful for a read-only Indexer tailing
+	// a primary; see ColumnFamily.Refresh.
+	Reload() error
+	// NewIterator returns a snapshot-consistent IndexerIterator pinned
+	// at call time. Every backend must provide one so ColumnFamily's
+	// merged Iterator can walk it regardless of which is configured,
+	// even backends like HashIndex that don't otherwise benefit from
+	// ordered access.
+	NewIterator() IndexerIterator
+	// CopyTo takes a bounded, point-in-time copy of this backend's
+	// on-disk log into dstDir and returns the file name written, for
+	// ColumnFamily.Snapshot. The caller must already exclude concurrent
+	// Put/Delete (e.g. by holding flushLock) for the copy to be
+	// consistent.
+	CopyTo(dstDir string) (string, error)
+}
+
+// copyFileRange copies the first n bytes of src into a new file at
+// dstPath, creating or truncating it as needed. It is shared by every
+// backend's CopyTo, since each keeps its keydir behind a single
+// append-only log that may still be open for writes.
+func copyFileRange(src *os.File, dstPath string, n int64) error {
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, io.NewSectionReader(src, 0, n))
+	return err
+}
+
+// NewIndexer dispatches to the backend named by opts.IndexType.
+func NewIndexer(opts *BPTreeOptions) (Indexer, error) {
+	switch opts.IndexType {
+	case BptreeBoltDB:
+		return newBptreeIndexer(opts)
+	case HashIndex:
+		return newHashIndexer(opts)
+	default:
+		return nil, ErrUnsupportedIndexType
+	}
+}
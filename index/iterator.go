@@ -0,0 +1,17 @@
+package index
+
+// IndexerIterator lets callers walk an Indexer's key space in order,
+// in addition to the point Get/Put/Delete the Indexer interface already
+// exposes. Implementations pin a read-only transaction (or equivalent
+// consistent view) at creation time, so writes that happen afterwards
+// are not visible through the iterator.
+type IndexerIterator interface {
+	SeekGE(key []byte) bool
+	SeekLT(key []byte) bool
+	Next() bool
+	Prev() bool
+	Valid() bool
+	Key() []byte
+	Meta() *IndexerMeta
+	Close() error
+}
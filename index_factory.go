@@ -0,0 +1,27 @@
+package lotusdb
+
+import "github.com/flower-corp/lotusdb/index"
+
+// IndexerFactory builds the index.Indexer a ColumnFamily uses to store
+// keys and meta info. Set ColumnFamilyOptions.IndexerFactory to plug in
+// a backend other than the default index.BptreeBoltDB one, e.g. the
+// hash index, for cfs that mostly do point lookups.
+type IndexerFactory func(opts *index.BPTreeOptions) (index.Indexer, error)
+
+// defaultIndexerFactory builds the index.BptreeBoltDB indexer
+// OpenColumnFamily has always used, preserved here so existing callers
+// that never set IndexerFactory keep the same behavior.
+func defaultIndexerFactory(opts *index.BPTreeOptions) (index.Indexer, error) {
+	opts.IndexType = index.BptreeBoltDB
+	return index.NewIndexer(opts)
+}
+
+// HashIndexerFactory builds the on-disk hash indexer from the index
+// package. It keeps its keys unordered, so writes skip the sorted
+// insert the default backend does, while range scans still work but
+// sort the whole keydir each time an iterator is created; it is a good
+// fit for cfs that mostly call Get.
+func HashIndexerFactory(opts *index.BPTreeOptions) (index.Indexer, error) {
+	opts.IndexType = index.HashIndex
+	return index.NewIndexer(opts)
+}
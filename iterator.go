@@ -0,0 +1,278 @@
+package lotusdb
+
+import (
+	"bytes"
+	"time"
+)
+
+// IterOptions holds the options for NewIterator.
+type IterOptions struct {
+	// Prefix filters the iterator to keys sharing this prefix. Empty
+	// means no filtering.
+	Prefix []byte
+	// Reverse iterates from the largest key to the smallest.
+	Reverse bool
+}
+
+// iterSource is a single ordered source of key/value pairs that the
+// merged Iterator reads from: either a memtable's skiplist or the
+// bptree indexer.
+type iterSource interface {
+	SeekGE(key []byte) bool
+	SeekLT(key []byte) bool
+	Next() bool
+	Prev() bool
+	Valid() bool
+	Key() []byte
+	// rawValue returns the entry's value, whether it is a tombstone, and
+	// an error if resolving the value (e.g. a vlog read) failed.
+	rawValue() ([]byte, bool, error)
+}
+
+// Iterator gives a snapshot-consistent, merged view over the active
+// memtable, the immutable memtables, and the B+ tree indexer, resolving
+// values that live in the value log transparently. The view is pinned
+// at construction time: the memtable slice is copied and the indexer is
+// read inside its own read transaction, so writes that happen after
+// NewIterator returns are not visible to it.
+type Iterator struct {
+	cf      *ColumnFamily
+	opts    IterOptions
+	sources []iterSource
+	// cur is the index into sources currently positioned at the
+	// smallest (or, if Reverse, largest) valid key, or -1 if none are
+	// valid.
+	cur int
+	key []byte
+	val []byte
+	err error
+	// stepFn moves a source off its current key in whatever direction
+	// the active seek/advance call is traversing; cmpRev is the
+	// matching direction for less, used to pick the new winner. Both
+	// are set by seek/advance before settle runs, and reused by settle
+	// itself when it needs to step past a tombstone.
+	stepFn func(iterSource) bool
+	cmpRev bool
+}
+
+// NewIterator returns an Iterator over this column family's current
+// contents. The returned Iterator must be closed with Close once the
+// caller is done with it, to release the pinned indexer transaction.
+func (cf *ColumnFamily) NewIterator(opts IterOptions) *Iterator {
+	tables := cf.getMemtables()
+	sources := make([]iterSource, 0, len(tables)+1)
+	for _, mem := range tables {
+		sources = append(sources, newMemtableIterSource(mem))
+	}
+
+	cf.mu.RLock()
+	idxIter := cf.indexer.NewIterator()
+	cf.mu.RUnlock()
+	sources = append(sources, newIndexerIterSource(cf, idxIter))
+
+	return &Iterator{cf: cf, opts: opts, sources: sources, cur: -1}
+}
+
+// SeekGE positions the iterator at the first key >= key.
+func (it *Iterator) SeekGE(key []byte) bool {
+	return it.seek(func(s iterSource) bool { return s.SeekGE(key) }, false)
+}
+
+// SeekLT positions the iterator at the last key < key.
+func (it *Iterator) SeekLT(key []byte) bool {
+	return it.seek(func(s iterSource) bool { return s.SeekLT(key) }, true)
+}
+
+func (it *Iterator) seek(do func(iterSource) bool, reverse bool) bool {
+	for _, s := range it.sources {
+		do(s)
+	}
+	it.cmpRev = reverse
+	if reverse {
+		it.stepFn = func(s iterSource) bool { return s.Prev() }
+	} else {
+		it.stepFn = func(s iterSource) bool { return s.Next() }
+	}
+	it.cur = it.findWinner(reverse)
+	return it.settle()
+}
+
+// Next advances the iterator to the next key in the merged view.
+func (it *Iterator) Next() bool {
+	return it.advance(it.opts.Reverse)
+}
+
+// Prev moves the iterator to the previous key in the merged view.
+func (it *Iterator) Prev() bool {
+	return it.advance(!it.opts.Reverse)
+}
+
+// advance moves to the next smaller key if reverse is set, else to the
+// next larger one.
+func (it *Iterator) advance(reverse bool) bool {
+	if it.cur < 0 {
+		return false
+	}
+	if reverse != it.cmpRev {
+		// sources not parked on it.key sit beyond it in the old
+		// direction; reposition every source around it.key first.
+		for _, s := range it.sources {
+			if reverse {
+				s.SeekLT(it.key)
+			} else {
+				s.SeekGE(it.key)
+			}
+		}
+	}
+	it.cmpRev = reverse
+	if reverse {
+		it.stepFn = func(s iterSource) bool { return s.Prev() }
+	} else {
+		it.stepFn = func(s iterSource) bool { return s.Next() }
+	}
+	it.stepSourcesAt(it.key)
+	it.cur = it.findWinner(reverse)
+	return it.settle()
+}
+
+// findWinner returns the index of the valid source whose key sorts
+// first in the direction reverse indicates (largest key if reverse,
+// smallest otherwise), or -1 if none are valid.
+func (it *Iterator) findWinner(reverse bool) int {
+	found := -1
+	for i, s := range it.sources {
+		if !s.Valid() {
+			continue
+		}
+		if found == -1 || it.less(s.Key(), it.sources[found].Key(), reverse) {
+			found = i
+		}
+	}
+	return found
+}
+
+// stepSourcesAt moves every source currently parked on key past it,
+// using it.stepFn. Any source sitting on the same key as the winner is
+// a shadowed, older version and must also move past it.
+func (it *Iterator) stepSourcesAt(key []byte) {
+	for _, s := range it.sources {
+		if s.Valid() && bytes.Equal(s.Key(), key) {
+			_ = it.stepFn(s)
+		}
+	}
+}
+
+// settle resolves the winning source's value (following the vlog
+// pointer if needed) and caches key/value for Key/Value. A winner whose
+// freshest version is a tombstone is not surfaced as Valid - Scan can
+// afford to filter those out itself (value != nil), but a caller
+// driving SeekGE/Next directly cannot, so settle steps past it and
+// keeps looking rather than stopping on a deleted key.
+func (it *Iterator) settle() bool {
+	it.err = nil
+	for {
+		if it.cur < 0 {
+			it.key, it.val = nil, nil
+			return false
+		}
+		s := it.sources[it.cur]
+		if !s.Valid() {
+			it.cur = -1
+			it.key, it.val = nil, nil
+			return false
+		}
+		if len(it.opts.Prefix) > 0 && !bytes.HasPrefix(s.Key(), it.opts.Prefix) {
+			it.cur = -1
+			it.key, it.val = nil, nil
+			return false
+		}
+
+		key := append([]byte(nil), s.Key()...)
+		val, tombstone, err := s.rawValue()
+		if err != nil {
+			it.key, it.val, it.err = key, nil, err
+			return true
+		}
+		if !tombstone {
+			it.key, it.val = key, val
+			return true
+		}
+
+		it.stepSourcesAt(key)
+		it.cur = it.findWinner(it.cmpRev)
+	}
+}
+
+func (it *Iterator) less(a, b []byte, reverse bool) bool {
+	if reverse {
+		return bytes.Compare(a, b) > 0
+	}
+	return bytes.Compare(a, b) < 0
+}
+
+// Valid reports whether the iterator is positioned at a live entry.
+func (it *Iterator) Valid() bool {
+	return it.cur >= 0
+}
+
+// Key returns the key at the current position.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value at the current position, resolving it from
+// the value log if it was stored there rather than inline. settle
+// already did this resolution (and cached the result in it.val) the
+// last time the iterator moved, so this just returns that cached value
+// instead of re-resolving it - re-calling rawValue here would read the
+// value log a second time for every Value() call on a vlog-backed key.
+func (it *Iterator) Value() ([]byte, error) {
+	if it.cur < 0 {
+		return nil, nil
+	}
+	return it.val, it.err
+}
+
+// Close releases resources pinned by the iterator, such as the
+// indexer's read transaction.
+func (it *Iterator) Close() error {
+	for _, s := range it.sources {
+		if closer, ok := s.(interface{ close() error }); ok {
+			if err := closer.close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Scan calls fn for every key in [start, end) in ascending order,
+// resolving values transparently from the value log, stopping early if
+// fn returns false. A nil end scans to the end of the keyspace.
+func (cf *ColumnFamily) Scan(start, end []byte, fn func(key, value []byte) bool) error {
+	it := cf.NewIterator(IterOptions{})
+	defer it.Close()
+
+	ok := it.SeekGE(start)
+	for ok {
+		if end != nil && bytes.Compare(it.Key(), end) >= 0 {
+			break
+		}
+		value, err := it.Value()
+		if err != nil {
+			return err
+		}
+		if value != nil && !fn(it.Key(), value) {
+			break
+		}
+		ok = it.Next()
+	}
+	return nil
+}
+
+// expired reports whether the unix expiry timestamp ts has passed.
+// indexerIterSource uses it to drop TTL-expired value log entries as
+// they are walked; memtableIterSource does not check expiry.
+func expired(ts int64) bool {
+	return ts != 0 && ts <= time.Now().Unix()
+}
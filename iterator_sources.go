@@ -0,0 +1,69 @@
+package lotusdb
+
+import "github.com/flower-corp/lotusdb/index"
+
+// memtableIterSource adapts a memtable's skiplist into an iterSource.
+// Values are always stored inline in the memtable, so rawValue never
+// needs to resolve a vlog pointer.
+type memtableIterSource struct {
+	mem  *memtable
+	iter *memtableIterator
+}
+
+func newMemtableIterSource(mem *memtable) *memtableIterSource {
+	return &memtableIterSource{mem: mem, iter: mem.skl.NewIterator()}
+}
+
+func (s *memtableIterSource) SeekGE(key []byte) bool { return s.iter.SeekGE(key) }
+func (s *memtableIterSource) SeekLT(key []byte) bool { return s.iter.SeekLT(key) }
+func (s *memtableIterSource) Next() bool             { return s.iter.Next() }
+func (s *memtableIterSource) Prev() bool             { return s.iter.Prev() }
+func (s *memtableIterSource) Valid() bool            { return s.iter.Valid() }
+func (s *memtableIterSource) Key() []byte            { return s.iter.Key() }
+
+// rawValue returns the entry's value and whether it is a tombstone
+// (deleted key), matching the memtable.get convention used elsewhere in
+// the package. A memtable entry is always resolved inline, so this
+// never fails.
+func (s *memtableIterSource) rawValue() ([]byte, bool, error) {
+	return s.iter.Value(), s.iter.Deleted(), nil
+}
+
+// indexerIterSource adapts the B+ tree indexer's own read transaction
+// into an iterSource, resolving values that were moved into the value
+// log (indexMeta.Value empty, Fid/Offset set) as the merged Iterator
+// walks across it.
+type indexerIterSource struct {
+	cf   *ColumnFamily
+	iter index.IndexerIterator
+}
+
+func newIndexerIterSource(cf *ColumnFamily, iter index.IndexerIterator) *indexerIterSource {
+	return &indexerIterSource{cf: cf, iter: iter}
+}
+
+func (s *indexerIterSource) SeekGE(key []byte) bool { return s.iter.SeekGE(key) }
+func (s *indexerIterSource) SeekLT(key []byte) bool { return s.iter.SeekLT(key) }
+func (s *indexerIterSource) Next() bool             { return s.iter.Next() }
+func (s *indexerIterSource) Prev() bool             { return s.iter.Prev() }
+func (s *indexerIterSource) Valid() bool            { return s.iter.Valid() }
+func (s *indexerIterSource) Key() []byte            { return s.iter.Key() }
+
+func (s *indexerIterSource) rawValue() ([]byte, bool, error) {
+	meta := s.iter.Meta()
+	if len(meta.Value) != 0 {
+		return meta.Value, false, nil
+	}
+	ent, err := s.cf.vlog.Read(meta.Fid, meta.Offset)
+	if err != nil {
+		return nil, false, err
+	}
+	if expired(ent.ExpiredAt) {
+		return nil, true, nil
+	}
+	return ent.Value, false, nil
+}
+
+func (s *indexerIterSource) close() error {
+	return s.iter.Close()
+}
@@ -0,0 +1,82 @@
+package lotusdb
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flower-corp/lotusdb/util"
+)
+
+// Options holds the top-level configuration for a LotusDB instance,
+// shared by every ColumnFamily it opens.
+type Options struct {
+	// DBPath is the directory column families are created under when
+	// ColumnFamilyOptions.DirPath is left empty.
+	DBPath string
+
+	// MemoryBudget caps the total bytes used by active + immutable
+	// memtables across every column family this instance opens. 0
+	// means unbounded, matching the per-cf-only behavior LotusDB had
+	// before MemoryManager existed.
+	MemoryBudget int64
+}
+
+// LotusDB is the top-level handle applications open; it owns zero or
+// more ColumnFamily instances that share the same memory budget.
+type LotusDB struct {
+	mu      sync.RWMutex
+	opts    Options
+	cfs     map[string]*ColumnFamily
+	memMgr  *MemoryManager
+	closedC chan struct{}
+}
+
+// Open creates a LotusDB instance rooted at opts.DBPath.
+func Open(opts Options) (*LotusDB, error) {
+	if !util.PathExist(opts.DBPath) {
+		if err := os.MkdirAll(opts.DBPath, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	db := &LotusDB{
+		opts:    opts,
+		cfs:     make(map[string]*ColumnFamily),
+		memMgr:  NewMemoryManager(opts.MemoryBudget),
+		closedC: make(chan struct{}),
+	}
+	go db.memMgr.runPressureFlusher(db.closedC)
+	return db, nil
+}
+
+// Close closes every open column family and stops the memory manager's
+// background flusher.
+func (db *LotusDB) Close() error {
+	close(db.closedC)
+
+	db.mu.Lock()
+	cfs := make([]*ColumnFamily, 0, len(db.cfs))
+	for _, cf := range db.cfs {
+		cfs = append(cfs, cf)
+	}
+	db.mu.Unlock()
+
+	var err error
+	for _, cf := range cfs {
+		if cerr := cf.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (db *LotusDB) getColumnFamily(name string) *ColumnFamily {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.cfs[name]
+}
+
+// pressureFlushInterval is how often the background flusher checks
+// whether the shared memory budget is over pressure.
+const pressureFlushInterval = 50 * time.Millisecond
@@ -0,0 +1,215 @@
+package lotusdb
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultWaitMemSpaceTimeout bounds how long a writer blocks waiting for
+// memory budget to free up before giving up with ErrWaitMemSpaceTimeout.
+const defaultWaitMemSpaceTimeout = 100 * time.Millisecond
+
+// memtableInfo is the bookkeeping the MemoryManager needs in order to pick
+// a flush victim under pressure, without importing the flush machinery
+// itself.
+type memtableInfo struct {
+	cf      *ColumnFamily
+	fid     uint32
+	size    int64
+	addedAt time.Time
+}
+
+// MemoryManager enforces a single byte budget for active + immutable
+// memtables shared across every ColumnFamily opened by a LotusDB instance.
+// Without it, each cf blocks writers independently in waitWritesMemSpace,
+// so an operator has no way to cap total RAM usage once more than one cf
+// is open.
+//
+// Accounting lives entirely in track/untrack, keyed by (cf, memtable
+// fid), using each memtable's absolute skiplist size: Reserve only
+// gates writers against the budget, it never itself adds to used, so
+// there is exactly one writer of used and no way for the two to drift
+// apart. runPressureFlusher asks victim() to pick the largest/oldest
+// memtable across all cfs once the budget is exhausted, and flushes it
+// through the same ColumnFamily.Flush callers use explicitly.
+type MemoryManager struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	maxBytes int64
+	used     int64
+	tables   map[*ColumnFamily]map[uint32]*memtableInfo
+}
+
+// NewMemoryManager creates a MemoryManager with a global budget of
+// maxBytes across all column families. A maxBytes of 0 means unbounded,
+// and Reserve always succeeds immediately.
+func NewMemoryManager(maxBytes int64) *MemoryManager {
+	mm := &MemoryManager{
+		maxBytes: maxBytes,
+		tables:   make(map[*ColumnFamily]map[uint32]*memtableInfo),
+	}
+	mm.cond = sync.NewCond(&mm.mu)
+	return mm
+}
+
+// Reserve blocks until there is room for size more bytes in the budget,
+// or returns ErrWaitMemSpaceTimeout if there still isn't within
+// timeout. A timeout of 0 uses defaultWaitMemSpaceTimeout. Reserve does
+// not itself account size against the budget — call track once the
+// write has actually landed in a memtable, with that memtable's new
+// absolute size.
+func (mm *MemoryManager) Reserve(size uint32, timeout time.Duration) error {
+	if mm.maxBytes <= 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultWaitMemSpaceTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	// Wake every waiter once the deadline passes, even if nobody ever
+	// calls track/untrack, so Reserve cannot block past timeout.
+	// The broadcast takes mm.mu so it cannot land between the deadline
+	// check below and cond.Wait.
+	timer := time.AfterFunc(timeout, func() {
+		mm.mu.Lock()
+		mm.cond.Broadcast()
+		mm.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	for mm.used+int64(size) > mm.maxBytes {
+		if time.Now().After(deadline) {
+			return ErrWaitMemSpaceTimeout
+		}
+		mm.cond.Wait()
+	}
+	return nil
+}
+
+// Used returns the number of budget bytes currently accounted for.
+func (mm *MemoryManager) Used() int64 {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mm.used
+}
+
+// track records a cf's memtable as being size bytes, adjusting used by
+// the delta from whatever size was last recorded for (cf, fid) — so
+// calling it repeatedly as a memtable grows charges only the growth,
+// never the whole size again.
+func (mm *MemoryManager) track(cf *ColumnFamily, fid uint32, size int64) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	byFid, ok := mm.tables[cf]
+	if !ok {
+		byFid = make(map[uint32]*memtableInfo)
+		mm.tables[cf] = byFid
+	}
+	if info, ok := byFid[fid]; ok {
+		mm.used += size - info.size
+		info.size = size
+		return
+	}
+	byFid[fid] = &memtableInfo{cf: cf, fid: fid, size: size, addedAt: time.Now()}
+	mm.used += size
+	mm.cond.Broadcast()
+}
+
+// untrack drops bookkeeping for a memtable once it has been flushed (or
+// the cf has closed), releasing its last known size back to the
+// budget and waking any writer blocked in Reserve.
+func (mm *MemoryManager) untrack(cf *ColumnFamily, fid uint32) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	byFid := mm.tables[cf]
+	if byFid == nil {
+		return
+	}
+	if info, ok := byFid[fid]; ok {
+		mm.used -= info.size
+		if mm.used < 0 {
+			mm.used = 0
+		}
+		delete(byFid, fid)
+	}
+	if len(byFid) == 0 {
+		delete(mm.tables, cf)
+	}
+	mm.cond.Broadcast()
+}
+
+// pressure reports whether the manager is above its budget.
+func (mm *MemoryManager) pressure() bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mm.maxBytes > 0 && mm.used > mm.maxBytes
+}
+
+// victim returns the column family and memtable fid that should be
+// flushed next across every tracked cf: the largest memtable, breaking
+// ties with the oldest. It returns ok=false if nothing is tracked.
+func (mm *MemoryManager) victim() (cf *ColumnFamily, fid uint32, ok bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	var best *memtableInfo
+	for _, byFid := range mm.tables {
+		for _, info := range byFid {
+			if best == nil ||
+				info.size > best.size ||
+				(info.size == best.size && info.addedAt.Before(best.addedAt)) {
+				best = info
+			}
+		}
+	}
+	if best == nil {
+		return nil, 0, false
+	}
+	return best.cf, best.fid, true
+}
+
+// runPressureFlusher is the background flusher the MemoryManager's
+// budget is actually enforced by: as long as the manager is over
+// budget, it repeatedly picks the current victim() and flushes it,
+// which hands it to the cf's own flush path — untrack (called once
+// that flush completes, see ColumnFamily.flushMemtable) is what brings
+// used back down. It stops when stopC is closed, e.g. from
+// LotusDB.Close.
+func (mm *MemoryManager) runPressureFlusher(stopC <-chan struct{}) {
+	ticker := time.NewTicker(pressureFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C:
+			for mm.pressure() {
+				cf, fid, ok := mm.victim()
+				if !ok {
+					break
+				}
+				// Flush(true) only ever rotates whatever is currently the
+				// active memtable. If the victim is already an immutable
+				// memtable, it has already been rotated out and queued on
+				// flushChn by a prior Flush call, so calling Flush(true)
+				// again here would instead rotate out cf's unrelated
+				// (and possibly near-empty) active memtable without
+				// doing anything for the memtable actually driving
+				// pressure. There is nothing more to do this tick; the
+				// async flush already in flight will untrack it.
+				cf.mu.RLock()
+				isActive := cf.activeMem.fid == fid
+				cf.mu.RUnlock()
+				if !isActive {
+					break
+				}
+				if err := cf.Flush(true); err != nil {
+					break
+				}
+			}
+		}
+	}
+}
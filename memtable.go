@@ -0,0 +1,233 @@
+package lotusdb
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/flower-corp/lotusdb/logfile"
+)
+
+// memOptions configures openMemtable.
+type memOptions struct {
+	path       string
+	fid        uint32
+	fsize      int64
+	ioType     logfile.IOType
+	memSize    uint32
+	bytesFlush uint32
+	readOnly   bool
+}
+
+// memEntry is a single key's current value in a memtable's skiplist.
+type memEntry struct {
+	value     []byte
+	tombstone bool
+	expiredAt int64
+}
+
+// skiplist is a minimal in-memory sorted keydir backing a memtable. It
+// is named after (and stands in for) the arena skiplist the real
+// memtable uses, so the rest of the package can talk about
+// mem.skl.Size()/NewIterator() without caring which is underneath.
+type skiplist struct {
+	mu      sync.RWMutex
+	entries map[string]*memEntry
+	keys    []string
+	size    uint32
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{entries: make(map[string]*memEntry)}
+}
+
+func (s *skiplist) put(key []byte, e *memEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := string(key)
+	if _, ok := s.entries[k]; !ok {
+		i := sort.SearchStrings(s.keys, k)
+		s.keys = append(s.keys, "")
+		copy(s.keys[i+1:], s.keys[i:])
+		s.keys[i] = k
+	}
+	s.entries[k] = e
+	s.size += uint32(len(key) + len(e.value))
+}
+
+func (s *skiplist) get(key []byte) (*memEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[string(key)]
+	return e, ok
+}
+
+// Size returns the approximate number of bytes held in the skiplist.
+func (s *skiplist) Size() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// NewIterator returns a snapshot-consistent iterator: the sorted key
+// list and entries are copied under lock at construction time.
+func (s *skiplist) NewIterator() *memtableIterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, len(s.keys))
+	copy(keys, s.keys)
+	entries := make(map[string]*memEntry, len(s.entries))
+	for k, v := range s.entries {
+		entries[k] = v
+	}
+	return &memtableIterator{keys: keys, entries: entries, pos: -1}
+}
+
+// memtableIterator walks a skiplist snapshot in key order.
+type memtableIterator struct {
+	keys    []string
+	entries map[string]*memEntry
+	pos     int
+}
+
+func (it *memtableIterator) SeekGE(key []byte) bool {
+	it.pos = sort.SearchStrings(it.keys, string(key))
+	return it.Valid()
+}
+
+func (it *memtableIterator) SeekLT(key []byte) bool {
+	it.pos = sort.SearchStrings(it.keys, string(key)) - 1
+	return it.Valid()
+}
+
+func (it *memtableIterator) Next() bool {
+	it.pos++
+	return it.Valid()
+}
+
+func (it *memtableIterator) Prev() bool {
+	it.pos--
+	return it.Valid()
+}
+
+func (it *memtableIterator) Valid() bool {
+	return it.pos >= 0 && it.pos < len(it.keys)
+}
+
+func (it *memtableIterator) Key() []byte {
+	return []byte(it.keys[it.pos])
+}
+
+func (it *memtableIterator) Value() []byte {
+	return it.entries[it.keys[it.pos]].value
+}
+
+func (it *memtableIterator) Deleted() bool {
+	return it.entries[it.keys[it.pos]].tombstone
+}
+
+// memtable is the active or an immutable write buffer for a
+// ColumnFamily: a skiplist kept in sync with an on-disk WAL so its
+// contents survive a restart until they are flushed into the indexer.
+type memtable struct {
+	fid  uint32
+	skl  *skiplist
+	wal  *logfile.LogFile
+	opts memOptions
+}
+
+// openMemtable opens (or creates, unless opts.readOnly) the WAL file for
+// opts.fid and replays it into a fresh skiplist.
+func openMemtable(opts memOptions) (*memtable, error) {
+	wal, err := logfile.OpenLogFile(opts.path, opts.fid, opts.fsize, logfile.WAL, opts.ioType, opts.readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &memtable{fid: opts.fid, skl: newSkiplist(), wal: wal, opts: opts}
+	if err := table.replayWAL(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// replayWAL rebuilds the skiplist from the WAL's records, in order, so
+// a later record's value (or tombstone) for a key wins.
+func (m *memtable) replayWAL() error {
+	var offset int64
+	for offset < m.wal.Size() {
+		key, value, expiredAt, err := m.wal.ReadRecord(offset)
+		if err != nil {
+			break
+		}
+		if len(value) == 0 {
+			m.skl.put(key, &memEntry{tombstone: true})
+		} else {
+			m.skl.put(key, &memEntry{value: value, expiredAt: expiredAt})
+		}
+		offset += int64(recordSize(key, value))
+	}
+	return nil
+}
+
+func recordSize(key, value []byte) int {
+	return 16 + len(key) + len(value)
+}
+
+// isFull reports whether writing delta more bytes would push this
+// memtable over its configured MemtableSize.
+func (m *memtable) isFull(delta uint32) bool {
+	return m.skl.Size()+delta > m.opts.memSize
+}
+
+func (m *memtable) put(key, value []byte, _ bool, opt WriteOptions) error {
+	if _, err := m.wal.Write(key, value, opt.ExpiredAt); err != nil {
+		return err
+	}
+	m.skl.put(key, &memEntry{value: value, expiredAt: opt.ExpiredAt})
+	if opt.Sync {
+		return m.wal.Sync()
+	}
+	return nil
+}
+
+func (m *memtable) delete(key []byte, opt WriteOptions) error {
+	if _, err := m.wal.Write(key, nil, 0); err != nil {
+		return err
+	}
+	m.skl.put(key, &memEntry{tombstone: true})
+	if opt.Sync {
+		return m.wal.Sync()
+	}
+	return nil
+}
+
+// get returns (invalid, value): invalid is true if key has a tombstone
+// in this memtable (the caller must not fall through to older sources),
+// and value is non-empty if key has a live value here.
+func (m *memtable) get(key []byte) (bool, []byte) {
+	e, ok := m.skl.get(key)
+	if !ok {
+		return false, nil
+	}
+	if e.tombstone {
+		return true, nil
+	}
+	return false, e.value
+}
+
+// closeWAL closes the memtable's WAL file. A read-only memtable opened
+// with no WAL on disk yet (see ColumnFamily.openMemtables) has a nil
+// wal, and is a no-op here until Refresh reopens it.
+func (m *memtable) closeWAL() error {
+	if m.wal == nil {
+		return nil
+	}
+	return m.wal.Close()
+}
+
+func (m *memtable) syncWAL() error {
+	if m.wal == nil {
+		return nil
+	}
+	return m.wal.Sync()
+}
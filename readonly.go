@@ -0,0 +1,45 @@
+package lotusdb
+
+import "errors"
+
+// ErrNotReadOnly the column family was not opened read-only, so Refresh
+// does not apply to it.
+var ErrNotReadOnly = errors.New("column family is not opened read-only")
+
+// Refresh re-scans this column family's directory for WAL/vlog files
+// written since it was opened (or since the last Refresh) and reloads
+// the in-memory memtable list and bptree state accordingly. It is only
+// meaningful for a cf opened with ReadOnly/OpenColumnFamilyReadOnly: a
+// secondary instance calls it periodically to tail a primary that is
+// still writing to the same directory. On any other cf it returns
+// ErrNotReadOnly.
+func (cf *ColumnFamily) Refresh() error {
+	if !cf.opts.ReadOnly {
+		return ErrNotReadOnly
+	}
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if err := cf.indexer.Reload(); err != nil {
+		return err
+	}
+
+	// openMemtables rebuilds activeMem/immuMems from whatever WAL files
+	// are on disk right now; close the ones we have open first so we
+	// don't leak their file descriptors on reload.
+	if err := cf.activeMem.closeWAL(); err != nil {
+		return err
+	}
+	for _, mem := range cf.immuMems {
+		if err := mem.closeWAL(); err != nil {
+			return err
+		}
+	}
+	cf.activeMem, cf.immuMems = nil, nil
+
+	if err := cf.openMemtables(); err != nil {
+		return err
+	}
+	return cf.vlog.reopenSegments()
+}
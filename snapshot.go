@@ -0,0 +1,310 @@
+package lotusdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flower-corp/lotusdb/logfile"
+	"github.com/flower-corp/lotusdb/util"
+)
+
+const snapshotMetaName = "SNAPSHOT"
+
+var (
+	// ErrSnapshotDirNotEmpty the target snapshot directory already contains files.
+	ErrSnapshotDirNotEmpty = errors.New("snapshot dir must be empty")
+
+	// ErrInvalidSnapshot the snapshot descriptor is missing or corrupted.
+	ErrInvalidSnapshot = errors.New("invalid or missing snapshot descriptor")
+)
+
+// Snapshot describes a consistent point-in-time copy of a ColumnFamily.
+// It is written as JSON next to the hard-linked/copied files so that
+// OpenColumnFamily can be pointed at the snapshot directory and recover
+// deterministically, without replaying writes that happened after the
+// snapshot was taken.
+type Snapshot struct {
+	CfName string `json:"cf_name"`
+	Dir    string `json:"dir"`
+
+	// ActiveMemFid is the fid of the memtable that was active when the
+	// snapshot was taken.
+	ActiveMemFid uint32 `json:"active_mem_fid"`
+	// ImmuMemFids are the fids of the immutable memtables, oldest first.
+	ImmuMemFids []uint32 `json:"immu_mem_fids"`
+
+	// VlogFid/VlogOffset mark the value log head at snapshot time, so a
+	// restored cf never reads past entries that did not exist yet.
+	VlogFid    uint32 `json:"vlog_fid"`
+	VlogOffset int64  `json:"vlog_offset"`
+
+	// IndexerFiles are the indexer file names copied into the snapshot.
+	IndexerFiles []string `json:"indexer_files"`
+
+	CreatedAt int64 `json:"created_at"`
+}
+
+// Snapshot produces a consistent point-in-time copy of this column family's
+// WAL files, B+ tree indexer, and value log segments into dir. dir is
+// created if it does not exist and must otherwise be empty. WAL files and
+// sealed value log segments are hard-linked, falling back to a plain copy
+// across filesystems; the head value log segment (up to the head offset)
+// and the indexer's log are copied. flushLock is held for all of it.
+func (cf *ColumnFamily) Snapshot(dir string) (*Snapshot, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !util.PathExist(dir) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	} else if empty, err := isDirEmpty(dir); err != nil {
+		return nil, err
+	} else if !empty {
+		return nil, ErrSnapshotDirNotEmpty
+	}
+
+	// Block flush/compaction for the duration of the snapshot: not just
+	// while rotating the active memtable and reading the vlog/indexer
+	// heads, but for the indexer/vlog copies below too, since those are
+	// the same files flushMemtable writes into under this same lock -
+	// without holding it throughout, a flush landing mid-copy would make
+	// the copy neither the old nor the new point in time.
+	cf.flushLock.Lock()
+
+	// Rotate the active memtable out from under future writers before
+	// reading anything, exactly like Flush does. Without this, the
+	// active WAL file is still being appended to by concurrent
+	// PutWithOptions/DeleteWithOptions calls after we read its fid, and
+	// hard-linking it (rather than copying a frozen byte range) would
+	// let restore silently replay writes that happened after Snapshot
+	// returned - not a point-in-time copy at all. Once rotated, the old
+	// active memtable's WAL never gets another write, so linking it is
+	// safe, and it is queued for a normal flush like any other.
+	cf.mu.Lock()
+	rotated := cf.activeMem
+	newMem, err := cf.openNewActiveMem()
+	if err != nil {
+		cf.mu.Unlock()
+		cf.flushLock.Unlock()
+		return nil, err
+	}
+	cf.immuMems = append(cf.immuMems, rotated)
+	cf.activeMem = newMem
+
+	snap := &Snapshot{
+		CfName:       cf.opts.CfName,
+		Dir:          dir,
+		ActiveMemFid: rotated.fid,
+		CreatedAt:    time.Now().Unix(),
+	}
+	for _, mem := range cf.immuMems {
+		if mem == rotated {
+			continue
+		}
+		snap.ImmuMemFids = append(snap.ImmuMemFids, mem.fid)
+	}
+	vlogFid, vlogOffset := cf.vlog.Head()
+	snap.VlogFid, snap.VlogOffset = vlogFid, vlogOffset
+	cf.mu.Unlock()
+
+	err = snapshotWalFiles(cf.opts.DirPath, dir, snap.ActiveMemFid, snap.ImmuMemFids)
+	if err == nil {
+		err = cf.vlog.CopySegments(dir, snap.VlogFid, snap.VlogOffset)
+	}
+	var indexerFile string
+	if err == nil {
+		indexerFile, err = cf.indexer.CopyTo(dir)
+	}
+	cf.flushLock.Unlock()
+
+	// Only queue rotated for a normal flush once flushLock is released:
+	// flushMemtable (the only reader of flushChn) itself needs flushLock
+	// to run, so sending while still holding it here would deadlock the
+	// moment flushChn's buffer filled up, with nothing left to drain it.
+	cf.flushWg.Add(1)
+	cf.flushChn <- rotated
+
+	if err != nil {
+		return nil, err
+	}
+	snap.IndexerFiles = []string{indexerFile}
+
+	if err := writeSnapshotMeta(dir, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Snapshot produces a Snapshot for every open column family, each under its
+// own subdirectory named after the column family, rooted at dir.
+func (db *LotusDB) Snapshot(dir string) (map[string]*Snapshot, error) {
+	db.mu.RLock()
+	cfs := make(map[string]*ColumnFamily, len(db.cfs))
+	for name, cf := range db.cfs {
+		cfs[name] = cf
+	}
+	db.mu.RUnlock()
+
+	snaps := make(map[string]*Snapshot, len(cfs))
+	for name, cf := range cfs {
+		cfDir := filepath.Join(dir, name)
+		snap, err := cf.Snapshot(cfDir)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot column family %q: %w", name, err)
+		}
+		snaps[name] = snap
+	}
+	return snaps, nil
+}
+
+// RestoreFromSnapshot opens a ColumnFamily from a directory previously
+// produced by Snapshot. The snapshot directory becomes the new column
+// family's dir path, indexer dir, and value log dir, so callers that want
+// to keep the snapshot immutable should copy it first.
+func (db *LotusDB) RestoreFromSnapshot(snapshotDir string, opts ColumnFamilyOptions) (*ColumnFamily, error) {
+	meta, err := readSnapshotMeta(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	opts.CfName = meta.CfName
+	opts.DirPath = snapshotDir
+	opts.IndexerDir = snapshotDir
+	opts.ValueLogDir = snapshotDir
+	return db.OpenColumnFamily(opts)
+}
+
+// PruneSnapshots keeps at most keep snapshots under root, deleting the
+// oldest ones first. Snapshot age is determined by the CreatedAt field
+// recorded in each snapshot's descriptor. It is meant to be called
+// periodically (e.g. from a cron-style background goroutine) so operators
+// do not have to track snapshot retention by hand.
+func PruneSnapshots(root string, keep int) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	type snapDir struct {
+		path      string
+		createdAt int64
+	}
+	var snaps []snapDir
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		meta, err := readSnapshotMeta(path)
+		if err != nil {
+			// not a snapshot directory, skip it.
+			continue
+		}
+		snaps = append(snaps, snapDir{path: path, createdAt: meta.CreatedAt})
+	}
+	if len(snaps) <= keep {
+		return nil
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].createdAt < snaps[j].createdAt
+	})
+	for _, s := range snaps[:len(snaps)-keep] {
+		if err := os.RemoveAll(s.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSnapshotMeta(dir string, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, snapshotMetaName), data, 0644)
+}
+
+func readSnapshotMeta(dir string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotMetaName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrInvalidSnapshot
+		}
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, ErrInvalidSnapshot
+	}
+	return &snap, nil
+}
+
+func snapshotWalFiles(srcDir, dstDir string, activeFid uint32, immuFids []uint32) error {
+	wanted := make(map[uint32]bool, len(immuFids)+1)
+	wanted[activeFid] = true
+	for _, fid := range immuFids {
+		wanted[fid] = true
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), logfile.WalSuffixName) {
+			continue
+		}
+		fid, err := strconv.Atoi(strings.Split(entry.Name(), ".")[0])
+		if err != nil || !wanted[uint32(fid)] {
+			continue
+		}
+		if err := linkOrCopyFile(filepath.Join(srcDir, entry.Name()), filepath.Join(dstDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkOrCopyFile hard-links src at dst, falling back to a full copy when
+// src and dst live on different filesystems (hard links cannot cross
+// filesystem boundaries).
+func linkOrCopyFile(src, dst string) error {
+	if util.PathExist(dst) {
+		return nil
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func isDirEmpty(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
@@ -0,0 +1,13 @@
+// Package util collects small helpers shared across LotusDB's packages.
+package util
+
+import "os"
+
+// PathExist reports whether path exists on disk.
+func PathExist(path string) bool {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true
+	}
+	return !os.IsNotExist(err)
+}
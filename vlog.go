@@ -0,0 +1,252 @@
+package lotusdb
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flower-corp/lotusdb/index"
+	"github.com/flower-corp/lotusdb/logfile"
+)
+
+// discard receives IndexerMeta entries superseded by a newer write. It
+// is fed by the indexer's DiscardChn; listenDiscard drains it without
+// counting the entries per segment.
+type discard struct {
+	valChan chan *index.IndexerMeta
+}
+
+// vlogOptions configures openValueLog.
+type vlogOptions struct {
+	path       string
+	blockSize  int64
+	ioType     logfile.IOType
+	gcRatio    float64
+	gcInterval time.Duration
+	// readOnly opens every segment O_RDONLY instead of O_CREATE|O_RDWR,
+	// for a secondary instance tailing a primary's value log.
+	readOnly bool
+}
+
+// vlogEntry is a single value log record.
+type vlogEntry struct {
+	Value     []byte
+	ExpiredAt int64
+}
+
+// valueLog stores values too large to keep inline in the indexer,
+// across one or more fixed-size segment files, identified by fid.
+type valueLog struct {
+	mu        sync.RWMutex
+	opts      vlogOptions
+	segments  map[uint32]*logfile.LogFile
+	activeFid uint32
+	discard   *discard
+	cf        *ColumnFamily
+}
+
+func openValueLog(opts vlogOptions) (*valueLog, error) {
+	vlog := &valueLog{
+		opts:     opts,
+		segments: make(map[uint32]*logfile.LogFile),
+		discard:  &discard{valChan: make(chan *index.IndexerMeta, 1024)},
+	}
+	if err := vlog.openSegments(opts.ioType == logfile.MMap); err != nil {
+		return nil, err
+	}
+	go vlog.listenDiscard()
+	return vlog, nil
+}
+
+// openSegments (re)scans opts.path for existing segments and opens
+// them, creating the first one if none exist yet.
+func (vlog *valueLog) openSegments(mmap bool) error {
+	fids, err := scanSegmentFids(vlog.opts.path)
+	if err != nil {
+		return err
+	}
+	if len(fids) == 0 {
+		// a read-only instance must never create the first segment
+		// itself: if the primary hasn't written anything yet, there is
+		// simply nothing to open, and manufacturing one would collide
+		// with the primary's own first write. reopenSegments picks up
+		// the primary's first segment once it exists.
+		if vlog.opts.readOnly {
+			return nil
+		}
+		fids = []uint32{logfile.InitialLogFileId}
+	}
+
+	ioType := logfile.FileIO
+	if mmap {
+		ioType = logfile.MMap
+	}
+	for _, fid := range fids {
+		segment, err := logfile.OpenLogFile(vlog.opts.path, fid, vlog.opts.blockSize, logfile.ValueLog, ioType, vlog.opts.readOnly)
+		if err != nil {
+			return err
+		}
+		vlog.segments[fid] = segment
+		if fid > vlog.activeFid {
+			vlog.activeFid = fid
+		}
+	}
+	return nil
+}
+
+// reopenSegments re-scans the value log directory for segments written
+// since the last open (or the last reopenSegments), for read-only
+// secondary instances tailing a primary.
+func (vlog *valueLog) reopenSegments() error {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+
+	fids, err := scanSegmentFids(vlog.opts.path)
+	if err != nil {
+		return err
+	}
+	for _, fid := range fids {
+		if _, ok := vlog.segments[fid]; ok {
+			continue
+		}
+		segment, err := logfile.OpenLogFile(vlog.opts.path, fid, vlog.opts.blockSize, logfile.ValueLog, vlog.opts.ioType, true)
+		if err != nil {
+			return err
+		}
+		vlog.segments[fid] = segment
+		if fid > vlog.activeFid {
+			vlog.activeFid = fid
+		}
+	}
+	return nil
+}
+
+func scanSegmentFids(path string) ([]uint32, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var fids []uint32
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), logfile.VLogSuffixName) {
+			continue
+		}
+		fid, err := strconv.Atoi(strings.Split(entry.Name(), ".")[0])
+		if err != nil {
+			continue
+		}
+		fids = append(fids, uint32(fid))
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids, nil
+}
+
+// Write appends an entry to the active segment and returns its
+// location for the indexer to point at.
+func (vlog *valueLog) Write(value []byte, expiredAt int64) (fid uint32, offset int64, err error) {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+
+	segment := vlog.segments[vlog.activeFid]
+	offset, err = segment.Write(nil, value, expiredAt)
+	if err != nil {
+		return 0, 0, err
+	}
+	return vlog.activeFid, offset, nil
+}
+
+// Read resolves a value previously written at (fid, offset).
+func (vlog *valueLog) Read(fid uint32, offset int64) (*vlogEntry, error) {
+	vlog.mu.RLock()
+	segment, ok := vlog.segments[fid]
+	vlog.mu.RUnlock()
+	if !ok {
+		return &vlogEntry{}, nil
+	}
+
+	_, value, expiredAt, err := segment.ReadRecord(offset)
+	if err != nil {
+		return nil, err
+	}
+	return &vlogEntry{Value: value, ExpiredAt: expiredAt}, nil
+}
+
+// Head reports the active segment's fid and its current write offset,
+// i.e. the first offset not yet written, for callers (like Snapshot)
+// that need a stable upper bound on what to include.
+func (vlog *valueLog) Head() (fid uint32, offset int64) {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+	segment, ok := vlog.segments[vlog.activeFid]
+	if !ok {
+		return 0, 0
+	}
+	return vlog.activeFid, segment.Size()
+}
+
+// CopySegments takes a point-in-time copy of every segment up to and
+// including headFid into dstDir: sealed segments (fid < headFid) are
+// hard-linked (falling back to a full copy across filesystems) since
+// they never get another write once rotated out, and the head segment
+// is copied bounded to headOffset, so writes the active segment
+// receives after the caller took headFid/headOffset are not included.
+// Segments beyond headFid are left out entirely. Callers must already
+// hold flushLock so no flush is appending to the indexer/vlog
+// concurrently, the same way Snapshot uses it.
+func (vlog *valueLog) CopySegments(dstDir string, headFid uint32, headOffset int64) error {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+
+	for fid, segment := range vlog.segments {
+		if fid > headFid {
+			continue
+		}
+		dst := logfile.FileName(dstDir, fid, logfile.ValueLog)
+		if fid < headFid {
+			src := logfile.FileName(vlog.opts.path, fid, logfile.ValueLog)
+			if err := linkOrCopyFile(src, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := segment.CopyTo(dst, headOffset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync fsyncs every open segment.
+func (vlog *valueLog) Sync() error {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+	for _, segment := range vlog.segments {
+		if err := segment.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every open segment.
+func (vlog *valueLog) Close() error {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+	for _, segment := range vlog.segments {
+		if err := segment.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenDiscard drains the discard channel. GC compaction of segments
+// based on accumulated discard ratio is out of scope here; this just
+// keeps writers from blocking on a full channel.
+func (vlog *valueLog) listenDiscard() {
+	for range vlog.discard.valChan {
+	}
+}